@@ -0,0 +1,142 @@
+package main
+
+import (
+	gotypes "go/types"
+
+	"github.com/llir/llvm/ir"
+	irtypes "github.com/llir/llvm/ir/types"
+)
+
+// chanTypeFor returns (creating and caching it if necessary) the concrete
+// IR layout backing typ, a ring-buffered channel header matching what
+// go2ll_runtime.c's go2ll_chan_* functions expect:
+//
+//	{ elemSize i64, bufPtr i8*, bufLen i64, bufCap i64,
+//	  sendx i64, recvx i64, closed i1, lock i64 }
+//
+// The element type itself never appears in the struct: go2ll_chan_make is
+// passed the element's byte size, stored as elemSize, so that send/recv can
+// memcpy elements generically.
+func (t *translator) chanTypeFor(typ *gotypes.Chan) *irtypes.StructType {
+	if cached, ok := t.chanTypeCache[typ]; ok {
+		return cached
+	}
+
+	irType := irtypes.NewStruct(
+		irtypes.I64,   // elemSize
+		irtypes.I8Ptr, // bufPtr
+		irtypes.I64,   // bufLen
+		irtypes.I64,   // bufCap
+		irtypes.I64,   // sendx
+		irtypes.I64,   // recvx
+		irtypes.I1,    // closed
+		irtypes.I64,   // lock
+	)
+	t.chanTypeCache[typ] = irType
+	return irType
+}
+
+// mapTypeFor returns (creating and caching it if necessary) the concrete
+// IR layout backing typ, a bucketed hash-table header matching what
+// go2ll_runtime.c's go2ll_map_* functions expect:
+//
+//	{ count i64, B i8, buckets i8*, hash0 i32 }
+//
+// As with chanTypeFor, key and value types are conveyed to the runtime as
+// byte sizes rather than encoded in the struct; see mapRuntimeSizes.
+func (t *translator) mapTypeFor(typ *gotypes.Map) *irtypes.StructType {
+	if cached, ok := t.mapTypeCache[typ]; ok {
+		return cached
+	}
+
+	irType := irtypes.NewStruct(
+		irtypes.I64,   // count
+		irtypes.I8,    // B (log2 of bucket count)
+		irtypes.I8Ptr, // buckets
+		irtypes.I32,   // hash0
+	)
+	t.mapTypeCache[typ] = irType
+	return irType
+}
+
+// mapRuntimeSizes returns the key and value byte sizes that a
+// go2ll_map_make call site must pass for typ; the map stores them, so the
+// runtime knows how many bytes to compare and copy per key/value in later
+// lookup/assign/delete calls.
+func (t *translator) mapRuntimeSizes(typ *gotypes.Map) (keySize, valSize int64) {
+	return t.sizeof(typ.Key()), t.sizeof(typ.Elem())
+}
+
+// go2llKeyBitwise and go2llKeyString mirror the go2ll_key_kind enum in
+// go2ll_runtime.c; they select whether go2ll_map_find compares keys with a
+// flat memcmp of their in-memory bytes or, for string, by dereferencing
+// and comparing the pointed-to bytes.
+const (
+	go2llKeyBitwise = 0
+	go2llKeyString  = 1
+)
+
+// mapRuntimeKeyKind returns the go2ll_key_kind a go2ll_map_make call site
+// must pass for typ's key type. Every Go key type is bitwise-comparable
+// under Go's own equality rules except string, whose two-word header
+// {ptr, len} must compare equal by content rather than by pointer.
+func (t *translator) mapRuntimeKeyKind(typ *gotypes.Map) int {
+	if isString(typ.Key()) {
+		return go2llKeyString
+	}
+	return go2llKeyBitwise
+}
+
+// runtimeFuncs declares (once, lazily) the extern go2ll_chan_*/go2ll_map_*
+// functions implemented by go2ll_runtime.c and linked in at compile time.
+// SSA lowering for make/send/recv/index/assign/delete/range over chan and
+// map types calls through these rather than inlining the layout logic.
+func (t *translator) runtimeFuncs() *RuntimeFuncs {
+	if t.runtime != nil {
+		return t.runtime
+	}
+
+	i8p, i64, i32 := irtypes.I8Ptr, irtypes.I64, irtypes.I32
+	declare := func(name string, ret irtypes.Type, params ...irtypes.Type) *ir.Func {
+		return t.m.NewFunc(name, ret, toParams(params)...)
+	}
+
+	t.runtime = &RuntimeFuncs{
+		ChanMake: declare("go2ll_chan_make", i8p, i64, i64),
+		ChanSend: declare("go2ll_chan_send", irtypes.Void, i8p, i8p),
+		ChanRecv: declare("go2ll_chan_recv", irtypes.Void, i8p, i8p),
+
+		// key_kind (see mapRuntimeKeyKind) selects bitwise vs
+		// content-aware key comparison in go2ll_map_find.
+		MapMake:     declare("go2ll_map_make", i8p, i64, i64, i32),
+		MapLookup:   declare("go2ll_map_lookup", i8p, i8p, i8p),
+		MapAssign:   declare("go2ll_map_assign", irtypes.Void, i8p, i8p, i8p),
+		MapDelete:   declare("go2ll_map_delete", irtypes.Void, i8p, i8p),
+		MapIterInit: declare("go2ll_map_iter_init", i8p, i8p),
+		MapIterNext: declare("go2ll_map_iter_next", irtypes.I1, i8p, i8p, i8p),
+	}
+	return t.runtime
+}
+
+// RuntimeFuncs holds declarations for every go2ll_runtime.c entry point the
+// chan/map lowering needs, declared once per module.
+type RuntimeFuncs struct {
+	ChanMake *ir.Func
+	ChanSend *ir.Func
+	ChanRecv *ir.Func
+
+	MapMake     *ir.Func
+	MapLookup   *ir.Func
+	MapAssign   *ir.Func
+	MapDelete   *ir.Func
+	MapIterInit *ir.Func
+	MapIterNext *ir.Func
+}
+
+func toParams(types []irtypes.Type) []*ir.Param {
+	params := make([]*ir.Param, len(types))
+	for i, typ := range types {
+		params[i] = ir.NewParam("", typ)
+	}
+	return params
+}
@@ -0,0 +1,218 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+
+	"golang.org/x/tools/go/ssa"
+)
+
+// onOffFlag is a flag.Value accepting the "on"/"off" spelling the -escape
+// flag is documented with, alongside the usual bool spellings, while still
+// supporting bare `-escape`/`-escape=false` via IsBoolFlag.
+type onOffFlag bool
+
+func (f *onOffFlag) String() string {
+	if *f {
+		return "on"
+	}
+	return "off"
+}
+
+func (f *onOffFlag) Set(s string) error {
+	switch s {
+	case "on", "true", "1":
+		*f = true
+	case "off", "false", "0":
+		*f = false
+	default:
+		return fmt.Errorf("invalid value %q (want on, off, true or false)", s)
+	}
+	return nil
+}
+
+func (f *onOffFlag) IsBoolFlag() bool { return true }
+
+// escapeFlag enables escape analysis (the default). Setting -escape=off
+// disables it for debugging: every *ssa.Alloc and *ssa.MakeClosure is then
+// treated as escaping, matching the translator's old always-heap-allocate
+// behaviour.
+var escapeFlagValue = onOffFlag(true)
+var escapeFlag = &escapeFlagValue
+
+func init() {
+	flag.Var(escapeFlag, "escape", "enable escape analysis for stack allocation (disable with -escape=off)")
+}
+
+// escapeInfo records, for one ssa.Function, which allocation sites the
+// analysis proved do not escape the function and can therefore be
+// stack-allocated instead of going through the heap/runtime allocator.
+//
+// The analysis is flow-insensitive: it does not reason about control flow
+// within the function, only about where a value's address is ultimately
+// used. A value escapes if, anywhere in the function, it is:
+//
+//   - assigned to a global (*ssa.Global store),
+//   - returned from the function (*ssa.Return operand),
+//   - stored into another heap value (the destination of a *ssa.Store
+//     is itself an escaping value, e.g. a field of an escaping struct),
+//   - passed as an argument to any *ssa.Call (callees are not looked into,
+//     so every call is treated as unknown), or
+//   - captured by a *ssa.MakeClosure whose own closure value escapes.
+//
+// Anything not reached by one of those rules is conservatively assumed
+// non-escaping, i.e. the analysis only ever promotes allocations it can
+// prove safe, never demotes ones it can't decide on.
+type escapeInfo struct {
+	escapes map[ssa.Value]bool
+}
+
+// analyzeEscapes runs escape analysis over fn and every function reachable
+// from its MakeClosure sites, recursively: a captured variable can escape
+// through what the closure body itself does with it, independent of
+// whether the closure value is itself ever returned or stored to a global.
+func analyzeEscapes(fn *ssa.Function) *escapeInfo {
+	e := &escapeInfo{escapes: map[ssa.Value]bool{}}
+	if !*escapeFlag {
+		e.markAllEscaping(fn)
+		return e
+	}
+	e.run(fn, map[*ssa.Function]bool{})
+	return e
+}
+
+// run visits fn's instructions to a fixpoint, then recurses into each
+// MakeClosure's own function body so that a FreeVar escaping inside the
+// closure (e.g. `f := func(){ g = x }` where g is a package global) marks
+// the corresponding outer binding as escaping too, independent of whether
+// the *ssa.MakeClosure value itself ever escapes. visiting is carried down
+// the recursion to guard against a closure that (directly or through
+// further nested closures) captures itself.
+func (e *escapeInfo) run(fn *ssa.Function, visiting map[*ssa.Function]bool) {
+	if visiting[fn] {
+		return
+	}
+	visiting[fn] = true
+	defer delete(visiting, fn)
+
+	// A single pass isn't enough: whether a *ssa.MakeClosure or the target
+	// of a *ssa.Store escapes can depend on an escaping fact (e.g. a later
+	// `return cl`) established by an instruction visited afterwards. Run
+	// to a fixpoint so propagation isn't sensitive to instruction order.
+	for {
+		before := len(e.escapes)
+		for _, block := range fn.Blocks {
+			for _, instr := range block.Instrs {
+				e.visit(instr)
+			}
+		}
+		if len(e.escapes) == before {
+			break
+		}
+	}
+
+	for _, block := range fn.Blocks {
+		for _, instr := range block.Instrs {
+			mc, ok := instr.(*ssa.MakeClosure)
+			if !ok {
+				continue
+			}
+			e.run(mc.Fn.(*ssa.Function), visiting)
+			for i, fv := range mc.Fn.(*ssa.Function).FreeVars {
+				if e.escapes[fv] {
+					e.markEscaping(mc.Bindings[i])
+				}
+			}
+		}
+	}
+}
+
+// markAllEscaping is used under -escape=false to fall back to the old
+// always-heap-allocate behaviour without special-casing every call site.
+func (e *escapeInfo) markAllEscaping(fn *ssa.Function) {
+	for _, block := range fn.Blocks {
+		for _, instr := range block.Instrs {
+			if v, ok := instr.(ssa.Value); ok {
+				e.escapes[v] = true
+			}
+		}
+	}
+}
+
+func (e *escapeInfo) visit(instr ssa.Instruction) {
+	switch instr := instr.(type) {
+	case *ssa.Store:
+		if e.isHeapOrGlobal(instr.Addr) {
+			e.markEscaping(instr.Val)
+		}
+
+	case *ssa.Return:
+		for _, result := range instr.Results {
+			e.markEscaping(result)
+		}
+
+	case *ssa.Call:
+		// A call to a function this analysis cannot see into must be
+		// assumed to let every pointer argument escape.
+		for _, arg := range instr.Call.Args {
+			e.markEscaping(arg)
+		}
+
+	case *ssa.MakeClosure:
+		if e.escapes[instr] {
+			for _, binding := range instr.Bindings {
+				e.markEscaping(binding)
+			}
+		}
+	}
+}
+
+// isHeapOrGlobal reports whether addr refers to storage the analysis
+// already knows escapes: a package-level global (including a field or
+// element reached through one, e.g. `someGlobal.Field` or
+// `globalSlice[i]`, which lower to a FieldAddr/IndexAddr chain rooted at
+// the *ssa.Global rather than a Global itself), or a value already marked
+// as escaping.
+func (e *escapeInfo) isHeapOrGlobal(addr ssa.Value) bool {
+	for addr != nil {
+		if _, ok := addr.(*ssa.Global); ok {
+			return true
+		}
+		if e.escapes[addr] {
+			return true
+		}
+		switch x := addr.(type) {
+		case *ssa.FieldAddr:
+			addr = x.X
+		case *ssa.IndexAddr:
+			addr = x.X
+		default:
+			return false
+		}
+	}
+	return false
+}
+
+// markEscaping marks v, and transitively the value whose field or element
+// address it computes (through *ssa.FieldAddr/*ssa.IndexAddr), as escaping.
+func (e *escapeInfo) markEscaping(v ssa.Value) {
+	for v != nil {
+		e.escapes[v] = true
+		switch x := v.(type) {
+		case *ssa.FieldAddr:
+			v = x.X
+		case *ssa.IndexAddr:
+			v = x.X
+		default:
+			return
+		}
+	}
+}
+
+// escapesFunc reports whether an *ssa.Alloc, *ssa.MakeClosure, *ssa.MakeSlice
+// or composite-literal site needs a heap allocation. The IR emitter
+// consults this to choose alloca (non-escaping) vs a runtime allocation
+// call (escaping).
+func (e *escapeInfo) escapesFunc(v ssa.Value) bool {
+	return e.escapes[v]
+}
@@ -0,0 +1,156 @@
+package main
+
+import (
+	"fmt"
+	gotypes "go/types"
+	"strings"
+
+	"github.com/llir/llvm/ir"
+	"github.com/llir/llvm/ir/constant"
+	irtypes "github.com/llir/llvm/ir/types"
+	"github.com/llir/llvm/ir/value"
+)
+
+// itabTypeFor returns the LLVM struct type of the itab header shared by
+// every concrete type that implements iface: a type-descriptor pointer
+// followed by one method-pointer slot per method in the interface's
+// method set, in types.NewMethodSet order. The type depends only on the
+// interface (its method count is fixed), so it is cached per interface and
+// reused across every concrete type that implements it.
+func (t *translator) itabTypeFor(iface *gotypes.Interface) *irtypes.StructType {
+	if cached, ok := t.itabTypeCache[iface]; ok {
+		return cached
+	}
+
+	ms := gotypes.NewMethodSet(iface)
+	fields := make([]irtypes.Type, 1+ms.Len())
+	fields[0] = irtypes.I8Ptr // type descriptor for the concrete type
+	for i := 1; i < len(fields); i++ {
+		fields[i] = irtypes.I8Ptr // method pointer, bitcast per concrete type
+	}
+
+	irType := irtypes.NewStruct(fields...)
+	t.itabTypeCache[iface] = irType
+	return irType
+}
+
+// itabKey identifies one (concrete type, interface type) pair that the
+// program actually uses, i.e. one itab global to emit.
+type itabKey struct {
+	concrete gotypes.Type
+	iface    *gotypes.Interface
+}
+
+// itabFor returns (lazily emitting it if necessary) the constant itab
+// global for concrete implementing iface: a type descriptor followed by
+// the concrete type's methods in the interface's method-set order,
+// bitcast to i8*.
+func (t *translator) itabFor(concrete gotypes.Type, iface *gotypes.Interface) *ir.Global {
+	key := itabKey{concrete, iface}
+	if g, ok := t.itabGlobals[key]; ok {
+		return g
+	}
+
+	irType := t.itabTypeFor(iface)
+	ms := gotypes.NewMethodSet(iface)
+
+	fields := make([]constant.Constant, 1+ms.Len())
+	fields[0] = constant.NewBitCast(t.typeDescriptorFor(concrete), irtypes.I8Ptr)
+	for i := 0; i < ms.Len(); i++ {
+		sel := ms.At(i)
+		fn := t.concreteMethod(concrete, sel.Obj().(*gotypes.Func))
+		fields[i+1] = constant.NewBitCast(fn, irtypes.I8Ptr)
+	}
+
+	name := fmt.Sprintf("itab.%s.%s", concreteTypeName(concrete), ifaceName(iface))
+	g := t.m.NewGlobalDef(name, constant.NewStruct(irType, fields...))
+	g.Immutable = true
+
+	t.itabGlobals[key] = g
+	return g
+}
+
+// itabTypeDescriptor loads the type-descriptor word out of an itab global,
+// for use in a type assertion: `v, ok := x.(T)` compares this pointer
+// against T's own type descriptor rather than comparing itabs wholesale,
+// since two different interfaces implemented by the same concrete type
+// have distinct itabs but must share a type descriptor. iface is the
+// interface's static type, which determines the itab's LLVM struct layout.
+func (t *translator) itabTypeDescriptor(b *ir.Block, iface *gotypes.Interface, itabPtr value.Value) value.Value {
+	gep := b.NewGetElementPtr(t.itabTypeFor(iface), itabPtr,
+		constant.NewInt(irtypes.I32, 0), constant.NewInt(irtypes.I32, 0))
+	return b.NewLoad(irtypes.I8Ptr, gep)
+}
+
+// ifaceName returns a stable, bare-LLVM-identifier-safe name for iface.
+// goToIRType always hands itabTypeFor/itabFor the interface's *underlying*
+// type, so there is never a *gotypes.TypeName to key a name off here; this
+// sanitizes the method-set signature instead, which is already unique per
+// distinct interface shape.
+func ifaceName(iface *gotypes.Interface) string {
+	var b strings.Builder
+	for _, r := range iface.String() {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9', r == '_', r == '.':
+			b.WriteRune(r)
+		default:
+			b.WriteByte('_')
+		}
+	}
+	return b.String()
+}
+
+// typeDescriptorFor returns (lazily emitting it if necessary) a unique
+// global symbol identifying concrete's dynamic type. Its contents don't
+// matter — only its address does, since itabTypeDescriptor compares it for
+// pointer identity in type assertions — but it's still named after the
+// concrete type for readability in the emitted IR.
+func (t *translator) typeDescriptorFor(concrete gotypes.Type) *ir.Global {
+	if g, ok := t.typeDescriptors[concrete]; ok {
+		return g
+	}
+
+	g := t.m.NewGlobalDef("type."+concreteTypeName(concrete), constant.NewInt(irtypes.I8, 0))
+	g.Immutable = true
+
+	t.typeDescriptors[concrete] = g
+	return g
+}
+
+// concreteMethod resolves the *ir.Func implementing method on concrete: the
+// counterpart sel.Obj() finds on iface's method set in itabFor is the
+// interface's abstract *gotypes.Func, so concrete's own method set is
+// looked up by name to find concrete's matching *gotypes.Func, which
+// t.funcs (populated as each method is lowered) maps to the already-emitted
+// function.
+func (t *translator) concreteMethod(concrete gotypes.Type, method *gotypes.Func) *ir.Func {
+	ms := gotypes.NewMethodSet(concrete)
+	sel := ms.Lookup(method.Pkg(), method.Name())
+	if sel == nil {
+		panic(fmt.Sprintf("concreteMethod: %s has no method %s", concrete, method.Name()))
+	}
+
+	fn, ok := t.funcs[sel.Obj().(*gotypes.Func)]
+	if !ok {
+		panic(fmt.Sprintf("concreteMethod: %s.%s not lowered yet", concrete, method.Name()))
+	}
+	return fn
+}
+
+// concreteTypeName returns a stable name component for a concrete type
+// boxed into an itab. Interfaces are commonly satisfied by a
+// pointer-receiver type (`var x Interface = &T{}`), so concrete here is
+// just as often a *gotypes.Pointer to a named type as a named type
+// directly; *T and T are distinct concrete types (they can implement
+// different interfaces), so the pointer case is named after the pointee
+// with a "ptr." marker rather than being unwrapped away.
+func concreteTypeName(typ gotypes.Type) string {
+	if ptr, ok := typ.(*gotypes.Pointer); ok {
+		return "ptr." + concreteTypeName(ptr.Elem())
+	}
+	named, ok := typ.(*gotypes.Named)
+	if !ok {
+		panic(fmt.Sprintf("concreteTypeName: %s is not a named type or a pointer to one", typ))
+	}
+	return getTypeName(named)
+}
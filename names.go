@@ -0,0 +1,65 @@
+package main
+
+import (
+	"fmt"
+	gotypes "go/types"
+	"strings"
+)
+
+// qualifiedTypeName builds a deterministic, stable name for a named type
+// from its defining scope chain: package path, then one segment per
+// enclosing block scope between the package scope and the type's own
+// scope, then the declared name. Running the translator twice over the
+// same source therefore produces byte-identical IR symbols, and two
+// distinct `type T struct{...}` declarations nested in different inner
+// scopes (e.g. two different functions, or two arms of an if) still get
+// distinct names because their scope chains differ.
+func qualifiedTypeName(obj *gotypes.TypeName) string {
+	pkg := obj.Pkg()
+	if pkg == nil {
+		// Predeclared / universe types have no package and no useful scope
+		// chain; the plain name is already unique.
+		return obj.Name()
+	}
+
+	var segments []string
+	for s := obj.Parent(); s != nil && s != pkg.Scope(); s = s.Parent() {
+		segments = append(segments, fmt.Sprintf("b%d", scopeChildIndex(s)))
+	}
+	// segments were collected innermost-first; reverse to outermost-first.
+	for i, j := 0, len(segments)-1; i < j; i, j = i+1, j-1 {
+		segments[i], segments[j] = segments[j], segments[i]
+	}
+	segments = append(segments, obj.Name())
+
+	return pkg.Path() + "." + strings.Join(segments, ".")
+}
+
+// scopeChildIndex returns the index of s among its parent scope's children,
+// giving a stable, declaration-order-based discriminator for anonymous
+// block scopes (function bodies, if/for/switch blocks, ...) that otherwise
+// have no name of their own.
+func scopeChildIndex(s *gotypes.Scope) int {
+	parent := s.Parent()
+	for i := 0; i < parent.NumChildren(); i++ {
+		if parent.Child(i) == s {
+			return i
+		}
+	}
+	panic(fmt.Sprintf("scopeChildIndex: scope not found among parent's children"))
+}
+
+// instanceSuffix mangles a generic type's concrete type arguments into its
+// IR name, so that e.g. List[int] and List[string] get distinct symbols.
+// Each argument is named with getTypeName rather than .String(), so a
+// named type argument that is itself defined in an inner scope (e.g. two
+// different `type Inner struct{}` declared in two different functions,
+// both instantiated as List[Inner]) mangles to its distinct qualified
+// name instead of colliding on identical spelling.
+func instanceSuffix(args *gotypes.TypeList) string {
+	parts := make([]string, args.Len())
+	for i := range parts {
+		parts[i] = getTypeName(args.At(i))
+	}
+	return "[" + strings.Join(parts, ",") + "]"
+}
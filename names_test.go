@@ -0,0 +1,92 @@
+package main
+
+import (
+	"go/ast"
+	"go/parser"
+	"go/token"
+	gotypes "go/types"
+	"testing"
+)
+
+const namingTestSrc = `
+package sample
+
+type Outer struct{ X int }
+
+func F() {
+	type Inner struct{ X int }
+	_ = Inner{}
+	if true {
+		type Inner struct{ Y int }
+		_ = Inner{}
+	}
+}
+`
+
+// checkTypes type-checks namingTestSrc and returns the *gotypes.TypeName for
+// every "type Inner"/"type Outer" declaration it finds, in no particular
+// order (it ranges over info.Defs, a map).
+func checkTypes(t *testing.T) []*gotypes.TypeName {
+	t.Helper()
+
+	fset := token.NewFileSet()
+	file, err := parser.ParseFile(fset, "sample.go", namingTestSrc, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	info := &gotypes.Info{Defs: map[*ast.Ident]gotypes.Object{}}
+	conf := gotypes.Config{}
+	if _, err := conf.Check("sample", fset, []*ast.File{file}, info); err != nil {
+		t.Fatal(err)
+	}
+
+	var names []*gotypes.TypeName
+	for _, obj := range info.Defs {
+		tn, ok := obj.(*gotypes.TypeName)
+		if !ok {
+			continue
+		}
+		names = append(names, tn)
+	}
+	return names
+}
+
+// TestGetTypeNameDeterministic verifies that translating the same package
+// twice yields byte-identical IR type names, and that the two distinct
+// `type Inner struct{...}` declarations nested in different blocks of F
+// still get distinct names.
+//
+// Each run parses namingTestSrc into a fresh token.FileSet, so a
+// declaration's token.Pos (a byte offset into that file) is identical
+// across runs; it is used as the join key below instead of
+// Scope.String(), which embeds the scope's pointer address and so differs
+// between independent type-checking passes even over identical source.
+func TestGetTypeNameDeterministic(t *testing.T) {
+	first := map[token.Pos]string{}
+	for _, tn := range checkTypes(t) {
+		first[tn.Pos()] = getTypeName(tn.Type())
+	}
+
+	second := map[token.Pos]string{}
+	for _, tn := range checkTypes(t) {
+		second[tn.Pos()] = getTypeName(tn.Type())
+	}
+
+	if len(first) != len(second) {
+		t.Fatalf("got %d names in first run, %d in second", len(first), len(second))
+	}
+	for pos, name := range first {
+		if second[pos] != name {
+			t.Errorf("name for declaration at pos %d not stable across runs: %q vs %q", pos, name, second[pos])
+		}
+	}
+
+	seen := map[string]bool{}
+	for _, name := range first {
+		if seen[name] {
+			t.Errorf("name %q was not unique across distinct declarations", name)
+		}
+		seen[name] = true
+	}
+}
@@ -0,0 +1,104 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	gotypes "go/types"
+
+	"github.com/llir/llvm/ir"
+	irtypes "github.com/llir/llvm/ir/types"
+)
+
+// TargetInfo bundles everything the translator needs to know about the
+// machine it is generating code for: the Go compiler's notion of type
+// sizes (gotypes.Sizes), the LLVM target triple, and the LLVM data layout
+// string, so that e.g. a slice header's length/cap fields and the
+// width picked for Int/Uint/Uintptr agree with the target's word size.
+type TargetInfo struct {
+	Name       string
+	Sizes      gotypes.Sizes
+	Triple     string
+	DataLayout string
+
+	// WordType is the LLVM integer type used for Go's Int, Uint and
+	// Uintptr, and for slice/string length and capacity fields.
+	WordType *irtypes.IntType
+
+	basicToIR map[gotypes.BasicKind]irtypes.Type // lazily built, see basicKindToIR
+}
+
+var targets = map[string]*TargetInfo{
+	"amd64": {
+		Name:       "amd64",
+		Sizes:      gotypes.SizesFor("gc", "amd64"),
+		Triple:     "x86_64-unknown-linux-gnu",
+		DataLayout: "e-m:e-p270:32:32-p271:32:32-p272:64:64-i64:64-f80:128-n8:16:32:64-S128",
+		WordType:   irtypes.I64,
+	},
+	"arm64": {
+		Name:       "arm64",
+		Sizes:      gotypes.SizesFor("gc", "arm64"),
+		Triple:     "aarch64-unknown-linux-gnu",
+		DataLayout: "e-m:e-i8:8:32-i16:16:32-i64:64-i128:128-n32:64-S128",
+		WordType:   irtypes.I64,
+	},
+	"386": {
+		Name:       "386",
+		Sizes:      gotypes.SizesFor("gc", "386"),
+		Triple:     "i386-unknown-linux-gnu",
+		DataLayout: "e-m:e-p:32:32-p270:32:32-p271:32:32-p272:64:64-f64:32:64-f80:32-n8:16:32-S128",
+		WordType:   irtypes.I32,
+	},
+	"wasm32": {
+		Name:       "wasm32",
+		Sizes:      gotypes.SizesFor("gc", "wasm"),
+		Triple:     "wasm32-unknown-unknown",
+		DataLayout: "e-m:e-p:32:32-i64:64-n32:64-S128",
+		// Go's wasm port keeps 64-bit int/uint/uintptr despite 32-bit
+		// linear-memory pointers: word size and pointer size are
+		// independent here, and WordType must track Sizes.Sizeof(Int),
+		// not the pointer width in DataLayout.
+		WordType: irtypes.I64,
+	},
+}
+
+// applyToModule sets m's target triple and data layout to match target, so
+// that offsets computed by downstream LLVM tooling agree with t.target.Sizes.
+func (target *TargetInfo) applyToModule(m *ir.Module) {
+	m.TargetTriple = target.Triple
+	m.DataLayout = target.DataLayout
+}
+
+// ParseTarget looks up a TargetInfo by its `-target` flag name.
+func ParseTarget(name string) (*TargetInfo, error) {
+	target, ok := targets[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown target %q (want one of amd64, arm64, 386, wasm32)", name)
+	}
+	return target, nil
+}
+
+var targetFlag = flag.String("target", "amd64", "target platform: amd64, arm64, 386 or wasm32")
+
+// basicKindToIR is like the target-independent basicToIR map, but with the
+// word-sized entries (Int, Uint, Uintptr) and the string header's length
+// field resolved against target's word width instead of being hard-coded
+// to amd64's I64. The result is built once per target and cached.
+func (target *TargetInfo) basicKindToIR() map[gotypes.BasicKind]irtypes.Type {
+	if target.basicToIR != nil {
+		return target.basicToIR
+	}
+
+	m := make(map[gotypes.BasicKind]irtypes.Type, len(basicToIR))
+	for k, v := range basicToIR {
+		m[k] = v
+	}
+
+	m[gotypes.Int] = target.WordType
+	m[gotypes.Uint] = target.WordType
+	m[gotypes.Uintptr] = target.WordType
+	m[gotypes.String] = irtypes.NewStruct(irtypes.I8Ptr, target.WordType)
+
+	target.basicToIR = m
+	return m
+}
@@ -3,12 +3,24 @@ package main
 import (
 	"fmt"
 	gotypes "go/types"
-	"math/rand"
 
 	irtypes "github.com/llir/llvm/ir/types"
 )
 
 func (t *translator) goToIRType(typ gotypes.Type) irtypes.Type {
+	if named, ok := typ.(*gotypes.Named); ok && named.TypeParams().Len() > 0 && named.TypeArgs() == nil {
+		panic(fmt.Sprintf(
+			"goToIRType: %s is a generic type with no type arguments; "+
+				"translate a concrete instantiation instead", named))
+	}
+	if sig, ok := typ.(*gotypes.Signature); ok && sig.TypeParams().Len() > 0 {
+		panic(fmt.Sprintf(
+			"goToIRType: %s is a generic function signature; translate the "+
+				"instantiation from types.Info.Instances instead", sig))
+	}
+
+	typ = t.canonicalInstance(typ)
+
 	x, ok := t.goToIRTypeCache[typ]
 	if ok {
 		return x
@@ -58,12 +70,114 @@ func isNamedSignature(typ gotypes.Type) bool {
 	return ok
 }
 
-// getTypeName makes a unique type for a name. Note that the 'qualified' type
-// name might not be globally unique because named types may be defined within
-// an inner scope (such as a function, or if block)
+// getTypeName makes a deterministic, stable IR name for typ. It is keyed on
+// the defining *gotypes.TypeName's scope chain rather than typ.String(), so
+// two distinct inner-scope `type T struct{...}` definitions with the same
+// spelling still get distinct names, and running the translator twice over
+// the same source produces byte-identical output.
 func getTypeName(typ gotypes.Type) string {
-	// TODO(pwaller): Something better than a random int, which is a bit messy.
-	return fmt.Sprintf("%s-%d", typ.String(), rand.Int())
+	named, ok := typ.(*gotypes.Named)
+	if !ok {
+		// Non-named types reach here as generic type arguments mangled by
+		// instanceSuffix (e.g. the int in List[int]); their spelling is
+		// used as is.
+		return typ.String()
+	}
+
+	name := qualifiedTypeName(named.Obj())
+	if args := named.TypeArgs(); args != nil {
+		name += instanceSuffix(args)
+	}
+	return name
+}
+
+// canonicalInstance returns the gotypes.Type to actually use as the
+// goToIRTypeCache key for typ. For an instantiated generic named type this
+// is NOT typ itself: two *gotypes.Named values describing the same
+// (origin, type arguments) pair - as produced by, say, two different
+// packages that both instantiate the same generic, type-checked
+// independently - are distinct pointers despite being the identical
+// instantiation, so keying on typ directly would translate and emit a
+// duplicate (same-named, colliding) IR type def for each. Instead, the
+// first instantiation seen for a given (origin, type-argument) pair is
+// cached by a string key derived from them, and every later instantiation
+// describing the same pair is canonicalized to that first one, so they
+// share a single cache entry and a single IR type def.
+//
+// Every other type is its own canonical representative.
+func (t *translator) canonicalInstance(typ gotypes.Type) gotypes.Type {
+	named, ok := typ.(*gotypes.Named)
+	if !ok || named.TypeArgs() == nil {
+		return typ
+	}
+
+	key := instanceKey(named)
+	if canon, ok := t.genericInstanceCanon[key]; ok {
+		return canon
+	}
+	t.genericInstanceCanon[key] = named
+	return named
+}
+
+// instanceKey builds the (origin, typeArgs) string used to recognize two
+// *gotypes.Named instantiations - however they were produced - as the same
+// concrete type: the origin's package path and declared name, plus the
+// mangled type arguments.
+func instanceKey(named *gotypes.Named) string {
+	origin := named.Origin()
+	obj := origin.Obj()
+
+	pkgPath := ""
+	if obj.Pkg() != nil {
+		pkgPath = obj.Pkg().Path()
+	}
+
+	return pkgPath + "." + obj.Name() + instanceSuffix(named.TypeArgs())
+}
+
+// instancesOf returns every concrete instantiation of the generic named type
+// origin that the loader's type-checking discovered in the program, found by
+// scanning types.Info.Instances for instances whose origin matches.
+//
+// This drives monomorphization: rather than ever translating a generic
+// origin type directly (which would hit an unresolved TypeParam), the
+// translator only ever sees the concrete instantiations reachable from call
+// sites and composite literals.
+func (t *translator) instancesOf(origin *gotypes.Named) []*gotypes.Named {
+	var out []*gotypes.Named
+	for _, inst := range t.info.Instances {
+		named, ok := inst.Type.(*gotypes.Named)
+		if !ok || named.Origin() != origin {
+			continue
+		}
+		out = append(out, named)
+	}
+	return out
+}
+
+// monomorphizeNamed translates every instantiation of a generic named type
+// found in the program, so that each gets its own cached IR type def.
+func (t *translator) monomorphizeNamed(origin *gotypes.Named) {
+	for _, inst := range t.instancesOf(origin) {
+		t.goToIRType(inst)
+	}
+}
+
+// instancesOfFunc returns the concrete, substituted signature of every call
+// site in the program that instantiates the generic function origin.
+func (t *translator) instancesOfFunc(origin *gotypes.Func) []*gotypes.Signature {
+	var out []*gotypes.Signature
+	for ident, inst := range t.info.Instances {
+		sig, ok := inst.Type.(*gotypes.Signature)
+		if !ok {
+			continue
+		}
+		if used, ok := t.info.Uses[ident].(*gotypes.Func); !ok || used != origin {
+			continue
+		}
+		out = append(out, sig)
+	}
+	return out
 }
 
 func (t *translator) goToIRTypeImpl(typ gotypes.Type) irtypes.Type {
@@ -78,13 +192,16 @@ func (t *translator) goToIRTypeImpl(typ gotypes.Type) irtypes.Type {
 		return t.goBasicToIRType(typ)
 
 	case *gotypes.Chan:
-		return irtypes.NewPointer(&irtypes.StructType{})
+		return irtypes.NewPointer(t.chanTypeFor(typ))
 
 	case *gotypes.Interface:
-		return irtypes.NewStruct(irtypes.I8Ptr, irtypes.I8Ptr)
+		// { itab*, data i8* }: the first word points at the itab global for
+		// whichever concrete type currently populates the interface, the
+		// second is the (possibly boxed) receiver data. See itab.go.
+		return irtypes.NewStruct(irtypes.NewPointer(t.itabTypeFor(typ)), irtypes.I8Ptr)
 
 	case *gotypes.Map:
-		return irtypes.NewPointer(&irtypes.StructType{})
+		return irtypes.NewPointer(t.mapTypeFor(typ))
 
 	// case *gotypes.Named:
 
@@ -113,15 +230,18 @@ func (t *translator) goToIRTypeImpl(typ gotypes.Type) irtypes.Type {
 
 		irFunc := irtypes.NewFunc(irRetType, irParamTypes...)
 		irFuncPtr := irtypes.NewPointer(irFunc)
-		// { %funcType FuncPtr, i8* ClosureEnv }
+		// { %funcType FuncPtr, i8* ClosureEnv }. ClosureEnv is an opaque
+		// pointer either way, so a non-escaping *ssa.MakeClosure (see
+		// escape.go) can point it at a stack alloca instead of a heap
+		// allocation without changing this type.
 		return irtypes.NewStruct(irFuncPtr, irtypes.I8Ptr)
 
 	case *gotypes.Slice:
 		irElemType := t.goToIRType(typ.Elem())
 		return irtypes.NewStruct(
 			irtypes.NewPointer(irElemType),
-			irtypes.I64,
-			irtypes.I64,
+			t.target.WordType,
+			t.target.WordType,
 		)
 
 	case *gotypes.Struct:
@@ -140,6 +260,12 @@ func (t *translator) goToIRTypeImpl(typ gotypes.Type) irtypes.Type {
 		}
 		return irtypes.NewStruct(fields...)
 
+	case *gotypes.TypeParam:
+		panic(fmt.Sprintf(
+			"unresolved type parameter %s: no concrete instantiation is "+
+				"available here; the caller must substitute type arguments "+
+				"before reaching goToIRType", typ))
+
 	default:
 		panic(fmt.Sprintf("unimplemented type: %T: %s", typ, typ))
 	}
@@ -173,7 +299,7 @@ var basicToIR = map[gotypes.BasicKind]irtypes.Type{
 }
 
 func (t *translator) goBasicToIRType(typ *gotypes.Basic) irtypes.Type {
-	irTyp, ok := basicToIR[typ.Kind()]
+	irTyp, ok := t.target.basicKindToIR()[typ.Kind()]
 	if !ok {
 		panic(fmt.Sprintf("unknown kind %v: %v", typ.Kind(), typ))
 	}
@@ -289,4 +415,8 @@ func isMap(typ gotypes.Type) bool {
 	return ok
 }
 
-var sizeof = gotypes.SizesFor("gc", "amd64").Sizeof
+// sizeof returns the size in bytes of typ under the translator's target,
+// in place of the old hard-coded amd64 sizes.Sizeof.
+func (t *translator) sizeof(typ gotypes.Type) int64 {
+	return t.target.Sizes.Sizeof(typ)
+}